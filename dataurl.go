@@ -3,11 +3,10 @@ package dataurl
 import (
 	"bytes"
 	"encoding/base64"
-	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
-	"strconv"
+	"mime"
 )
 
 const (
@@ -37,13 +36,20 @@ func (mt *MediaType) ContentType() string {
 
 // String implements the Stringer interface.
 //
-// Params values are escaped with the Escape function, rather than in a quoted string.
+// Param values are formatted with mime.FormatMediaType, which quotes
+// them per RFC 2045 when needed. If the type or subtype isn't a valid
+// RFC 2045 token, mime.FormatMediaType gives up (it returns ""), in
+// which case param values fall back to being escaped with the Escape
+// function, rather than in a quoted string.
 func (mt *MediaType) String() string {
+	if s := mime.FormatMediaType(mt.ContentType(), mt.Params); s != "" {
+		return s
+	}
 	var buf bytes.Buffer
 	for k, v := range mt.Params {
 		fmt.Fprintf(&buf, ";%s=%s", k, EscapeString(v))
 	}
-	return mt.ContentType()+(&buf).String()
+	return mt.ContentType() + (&buf).String()
 }
 
 // DataURL is the combination of a MediaType describing the type of its Data.
@@ -76,8 +82,8 @@ func (du *DataURL) WriteTo(w io.Writer) (n int64, err error) {
 	ni, _ = fmt.Fprint(w, du.MediaType.String())
 	n += int64(ni)
 
-	if du.Encoding == EncodingBase64 {
-		ni, _ = fmt.Fprint(w, ";base64")
+	if du.Encoding != EncodingASCII {
+		ni, _ = fmt.Fprintf(w, ";%s", du.Encoding)
 		n += int64(ni)
 	}
 
@@ -94,6 +100,13 @@ func (du *DataURL) WriteTo(w io.Writer) (n int64, err error) {
 	} else if du.Encoding == EncodingASCII {
 		ni, _ = fmt.Fprint(w, Escape(du.Data))
 		n += int64(ni)
+	} else if enc, ok := lookupExtraEncoding(du.Encoding); ok {
+		encoder := enc.encode(w)
+		ni, err = encoder.Write(du.Data)
+		if err != nil {
+			return
+		}
+		encoder.Close()
 	} else {
 		err = fmt.Errorf("dataurl: invalid encoding %s", du.Encoding)
 		return
@@ -120,92 +133,76 @@ var base64DataReader encodedDataReader = func(s string) ([]byte, error) {
 	return []byte(data), nil
 }
 
-type parser struct {
-	du                  *DataURL
-	l                   *lexer
-	currentAttr         string
-	unquoteParamVal     bool
-	encodedDataReaderFn encodedDataReader
-}
-
-func (p *parser) parse() error {
-	for item := range p.l.items {
-		switch item.t {
-		case itemError:
-			return errors.New(item.String())
-		case itemMediaType:
-			p.du.MediaType.Type = item.val
-			// Should we clear the default
-			// "charset" parameter at this point?
-			delete(p.du.MediaType.Params, "charset")
-		case itemMediaSubType:
-			p.du.MediaType.Subtype = item.val
-		case itemParamAttr:
-			p.currentAttr = item.val
-		case itemLeftStringQuote:
-			p.unquoteParamVal = true
-		case itemParamVal:
-			var val string = item.val
-			if p.unquoteParamVal {
-				p.unquoteParamVal = false
-				us, err := strconv.Unquote("\"" + val + "\"")
-				if err != nil {
-					return err
-				}
-				val = us
-			} else {
-				us, err := UnescapeToString(val)
-				if err != nil {
-					return err
-				}
-				val = us
-			}
-			p.du.MediaType.Params[p.currentAttr] = val
-		case itemBase64Enc:
-			p.du.Encoding = EncodingBase64
-			p.encodedDataReaderFn = base64DataReader
-		case itemDataComma:
-			if p.encodedDataReaderFn == nil {
-				p.encodedDataReaderFn = asciiDataReader
-			}
-		case itemData:
-			reader, err := p.encodedDataReaderFn(item.val)
-			if err != nil {
-				return err
-			}
-			p.du.Data = reader
-		case itemEOF:
-			if p.du.Data == nil {
-				p.du.Data = []byte("")
-			}
-			return nil
+// decodePayload decodes the still-encoded payload left in s.b[s.pos:]
+// by scanHeader into du.Data. The base64 case decodes straight from
+// the source bytes with no intermediate string, since it's the common
+// case this package is optimized for; the rest go through the
+// string-based encodedDataReader funcs (asciiDataReader, or the decode
+// func held in extraEncodings for the built-in base32/base64url/ascii85
+// or anything added with RegisterEncoding), which is one allocation to
+// convert the
+// payload to a string.
+func (s *scanner) decodePayload(du *DataURL) error {
+	payload := s.b[s.pos:]
+	switch du.Encoding {
+	case EncodingBase64:
+		dst := make([]byte, base64.StdEncoding.DecodedLen(len(payload)))
+		n, err := base64.StdEncoding.Decode(dst, payload)
+		if err != nil {
+			return err
+		}
+		du.Data = dst[:n]
+	case EncodingASCII:
+		data, err := asciiDataReader(string(payload))
+		if err != nil {
+			return err
+		}
+		du.Data = data
+	default:
+		enc, ok := lookupExtraEncoding(du.Encoding)
+		if !ok {
+			return fmt.Errorf("dataurl: invalid encoding %s", du.Encoding)
+		}
+		data, err := enc.decode(string(payload))
+		if err != nil {
+			return err
 		}
+		du.Data = data
+	}
+	if du.Data == nil {
+		du.Data = []byte("")
 	}
-	panic("EOF not found")
+	return nil
 }
 
-// DecodeString decodes a Data URL scheme string.
-func DecodeString(s string) (*DataURL, error) {
+// Parse parses a data URL from raw bytes in a single pass, with no
+// goroutine or channel and, for the common "data:<mediatype>;base64,..."
+// case, no intermediate string conversion of the payload.
+func Parse(b []byte) (*DataURL, error) {
 	du := &DataURL{
 		MediaType: defaultMediaType(),
 		Encoding:  EncodingASCII,
 	}
-
-	parser := &parser{
-		du: du,
-		l:  lex(s),
+	s := &scanner{b: b}
+	if err := s.scanHeader(du); err != nil {
+		return nil, err
 	}
-	if err := parser.parse(); err != nil {
+	if err := s.decodePayload(du); err != nil {
 		return nil, err
 	}
 	return du, nil
 }
 
+// DecodeString decodes a Data URL scheme string.
+func DecodeString(str string) (*DataURL, error) {
+	return Parse([]byte(str))
+}
+
 // Decode decodes a Data URL scheme from a io.Reader.
 func Decode(r io.Reader) (*DataURL, error) {
 	data, err := ioutil.ReadAll(r)
 	if err != nil {
 		return nil, err
 	}
-	return DecodeString(string(data))
+	return Parse(data)
 }
@@ -11,7 +11,7 @@ import (
 
 type dataURLTest struct {
 	InputRawDataURL string
-	ExpectedItems   []item
+	ExpectedErr     string
 	ExpectedDataURL DataURL
 }
 
@@ -19,14 +19,7 @@ func genTestTable() []dataURLTest {
 	return []dataURLTest{
 		dataURLTest{
 			`data:;base64,aGV5YQ==`,
-			[]item{
-				item{itemDataPrefix, dataPrefix},
-				item{itemParamSemicolon, ";"},
-				item{itemBase64Enc, "base64"},
-				item{itemDataComma, ","},
-				item{itemData, "aGV5YQ=="},
-				item{itemEOF, ""},
-			},
+			"",
 			DataURL{
 				defaultMediaType(),
 				EncodingBase64,
@@ -35,17 +28,7 @@ func genTestTable() []dataURLTest {
 		},
 		dataURLTest{
 			`data:text/plain;base64,aGV5YQ==`,
-			[]item{
-				item{itemDataPrefix, dataPrefix},
-				item{itemMediaType, "text"},
-				item{itemMediaSep, "/"},
-				item{itemMediaSubType, "plain"},
-				item{itemParamSemicolon, ";"},
-				item{itemBase64Enc, "base64"},
-				item{itemDataComma, ","},
-				item{itemData, "aGV5YQ=="},
-				item{itemEOF, ""},
-			},
+			"",
 			DataURL{
 				MediaType{
 					"text",
@@ -58,21 +41,7 @@ func genTestTable() []dataURLTest {
 		},
 		dataURLTest{
 			`data:text/plain;charset=utf-8;base64,aGV5YQ==`,
-			[]item{
-				item{itemDataPrefix, dataPrefix},
-				item{itemMediaType, "text"},
-				item{itemMediaSep, "/"},
-				item{itemMediaSubType, "plain"},
-				item{itemParamSemicolon, ";"},
-				item{itemParamAttr, "charset"},
-				item{itemParamEqual, "="},
-				item{itemParamVal, "utf-8"},
-				item{itemParamSemicolon, ";"},
-				item{itemBase64Enc, "base64"},
-				item{itemDataComma, ","},
-				item{itemData, "aGV5YQ=="},
-				item{itemEOF, ""},
-			},
+			"",
 			DataURL{
 				MediaType{
 					"text",
@@ -87,25 +56,7 @@ func genTestTable() []dataURLTest {
 		},
 		dataURLTest{
 			`data:text/plain;charset=utf-8;foo=bar;base64,aGV5YQ==`,
-			[]item{
-				item{itemDataPrefix, dataPrefix},
-				item{itemMediaType, "text"},
-				item{itemMediaSep, "/"},
-				item{itemMediaSubType, "plain"},
-				item{itemParamSemicolon, ";"},
-				item{itemParamAttr, "charset"},
-				item{itemParamEqual, "="},
-				item{itemParamVal, "utf-8"},
-				item{itemParamSemicolon, ";"},
-				item{itemParamAttr, "foo"},
-				item{itemParamEqual, "="},
-				item{itemParamVal, "bar"},
-				item{itemParamSemicolon, ";"},
-				item{itemBase64Enc, "base64"},
-				item{itemDataComma, ","},
-				item{itemData, "aGV5YQ=="},
-				item{itemEOF, ""},
-			},
+			"",
 			DataURL{
 				MediaType{
 					"text",
@@ -121,31 +72,7 @@ func genTestTable() []dataURLTest {
 		},
 		dataURLTest{
 			`data:application/json;charset=utf-8;foo="b\"<@>\"r";style=unformatted%20json;base64,eyJtc2ciOiAiaGV5YSJ9`,
-			[]item{
-				item{itemDataPrefix, dataPrefix},
-				item{itemMediaType, "application"},
-				item{itemMediaSep, "/"},
-				item{itemMediaSubType, "json"},
-				item{itemParamSemicolon, ";"},
-				item{itemParamAttr, "charset"},
-				item{itemParamEqual, "="},
-				item{itemParamVal, "utf-8"},
-				item{itemParamSemicolon, ";"},
-				item{itemParamAttr, "foo"},
-				item{itemParamEqual, "="},
-				item{itemLeftStringQuote, "\""},
-				item{itemParamVal, `b\"<@>\"r`},
-				item{itemRightStringQuote, "\""},
-				item{itemParamSemicolon, ";"},
-				item{itemParamAttr, "style"},
-				item{itemParamEqual, "="},
-				item{itemParamVal, "unformatted%20json"},
-				item{itemParamSemicolon, ";"},
-				item{itemBase64Enc, "base64"},
-				item{itemDataComma, ","},
-				item{itemData, "eyJtc2ciOiAiaGV5YSJ9"},
-				item{itemEOF, ""},
-			},
+			"",
 			DataURL{
 				MediaType{
 					"application",
@@ -162,19 +89,12 @@ func genTestTable() []dataURLTest {
 		},
 		dataURLTest{
 			`data:xxx;base64,aGV5YQ==`,
-			[]item{
-				item{itemDataPrefix, dataPrefix},
-				item{itemError, "invalid character for media type"},
-			},
+			"invalid character for media type",
 			DataURL{},
 		},
 		dataURLTest{
 			`data:,`,
-			[]item{
-				item{itemDataPrefix, dataPrefix},
-				item{itemDataComma, ","},
-				item{itemEOF, ""},
-			},
+			"",
 			DataURL{
 				defaultMediaType(),
 				EncodingASCII,
@@ -183,12 +103,7 @@ func genTestTable() []dataURLTest {
 		},
 		dataURLTest{
 			`data:,A%20brief%20note`,
-			[]item{
-				item{itemDataPrefix, dataPrefix},
-				item{itemDataComma, ","},
-				item{itemData, "A%20brief%20note"},
-				item{itemEOF, ""},
-			},
+			"",
 			DataURL{
 				defaultMediaType(),
 				EncodingASCII,
@@ -197,17 +112,7 @@ func genTestTable() []dataURLTest {
 		},
 		dataURLTest{
 			`data:image/svg+xml-im.a.fake;base64,cGllLXN0b2NrX1RoaXJ0eQ==`,
-			[]item{
-				item{itemDataPrefix, dataPrefix},
-				item{itemMediaType, "image"},
-				item{itemMediaSep, "/"},
-				item{itemMediaSubType, "svg+xml-im.a.fake"},
-				item{itemParamSemicolon, ";"},
-				item{itemBase64Enc, "base64"},
-				item{itemDataComma, ","},
-				item{itemData, "cGllLXN0b2NrX1RoaXJ0eQ=="},
-				item{itemEOF, ""},
-			},
+			"",
 			DataURL{
 				MediaType{
 					"image",
@@ -221,21 +126,6 @@ func genTestTable() []dataURLTest {
 	}
 }
 
-func expectItems(expected, actual []item) bool {
-	if len(expected) != len(actual) {
-		return false
-	}
-	for i, _ := range expected {
-		if expected[i].t != actual[i].t {
-			return false
-		}
-		if expected[i].val != actual[i].val {
-			return false
-		}
-	}
-	return true
-}
-
 func equal(du1, du2 *DataURL) (bool, error) {
 	if !reflect.DeepEqual(du1.MediaType, du2.MediaType) {
 		return false, nil
@@ -254,38 +144,42 @@ func equal(du1, du2 *DataURL) (bool, error) {
 	return true, nil
 }
 
-func TestLexDataURLs(t *testing.T) {
+func TestDataURLs(t *testing.T) {
 	for _, test := range genTestTable() {
-		l := lex(test.InputRawDataURL)
-		items := make([]item, 0)
-		for item := range l.items {
-			items = append(items, item)
+		dataURL, err := Decode(strings.NewReader(test.InputRawDataURL))
+		if test.ExpectedErr == "" && err != nil {
+			t.Error(err)
+			continue
+		} else if test.ExpectedErr != "" && err == nil {
+			t.Errorf("Expected error \"%s\", got nil", test.ExpectedErr)
+			continue
+		} else if test.ExpectedErr != "" && err != nil {
+			if err.Error() != test.ExpectedErr {
+				t.Errorf("Expected error \"%s\", got \"%s\"", test.ExpectedErr, err.Error())
+			}
+			continue
 		}
-		if !expectItems(test.ExpectedItems, items) {
-			t.Errorf("Expected %v, got %v", test.ExpectedItems, items)
+
+		if ok, err := equal(dataURL, &test.ExpectedDataURL); err != nil {
+			t.Error(err)
+		} else if !ok {
+			t.Errorf("Expected %v, got %v", test.ExpectedDataURL, *dataURL)
 		}
 	}
 }
 
-func TestDataURLs(t *testing.T) {
+func TestParse(t *testing.T) {
 	for _, test := range genTestTable() {
-		var expectedItemError string
-		for _, item := range test.ExpectedItems {
-			if item.t == itemError {
-				expectedItemError = item.String()
-				break
-			}
-		}
-		dataURL, err := Decode(strings.NewReader(test.InputRawDataURL))
-		if expectedItemError == "" && err != nil {
+		dataURL, err := Parse([]byte(test.InputRawDataURL))
+		if test.ExpectedErr == "" && err != nil {
 			t.Error(err)
 			continue
-		} else if expectedItemError != "" && err == nil {
-			t.Errorf("Expected error \"%s\", got nil", expectedItemError)
+		} else if test.ExpectedErr != "" && err == nil {
+			t.Errorf("Expected error \"%s\", got nil", test.ExpectedErr)
 			continue
-		} else if expectedItemError != "" && err != nil {
-			if err.Error() != expectedItemError {
-				t.Errorf("Expected error \"%s\", got \"%s\"", expectedItemError, err.Error())
+		} else if test.ExpectedErr != "" && err != nil {
+			if err.Error() != test.ExpectedErr {
+				t.Errorf("Expected error \"%s\", got \"%s\"", test.ExpectedErr, err.Error())
 			}
 			continue
 		}
@@ -298,12 +192,28 @@ func TestDataURLs(t *testing.T) {
 	}
 }
 
+// TestParseAllocs guards against regressions in Parse's allocation
+// count for the common "data:image/png;base64,..." case. It doesn't
+// assert zero allocations: du.MediaType's Type/Subtype/Params map and
+// entry and du.Data all require at least one allocation each given the
+// public DataURL/MediaType struct shape, so a handful is the realistic
+// floor, not zero.
+func TestParseAllocs(t *testing.T) {
+	b := []byte("data:image/png;base64,aGV5YQ==")
+	allocs := testing.AllocsPerRun(1000, func() {
+		if _, err := Parse(b); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs > 6 {
+		t.Errorf("expected at most 6 allocs per Parse, got %v", allocs)
+	}
+}
+
 func BenchmarkLex(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		for _, test := range genTestTable() {
-			l := lex(test.InputRawDataURL)
-			for _ = range l.items {
-			}
+			_, _ = Parse([]byte(test.InputRawDataURL))
 		}
 	}
 }
@@ -316,13 +226,7 @@ func TestRegexp(t *testing.T) {
 		t.Fatal(err)
 	}
 	for _, test := range genTestTable() {
-		shouldMatch := true
-		for _, item := range test.ExpectedItems {
-			if item.t == itemError {
-				shouldMatch = false
-				break
-			}
-		}
+		shouldMatch := test.ExpectedErr == ""
 		// just test it matches, do not parse
 		if re.MatchString(test.InputRawDataURL) && !shouldMatch {
 			t.Error("doesn't match", test.InputRawDataURL)
@@ -343,3 +247,85 @@ func BenchmarkRegexp(b *testing.B) {
 		}
 	}
 }
+
+func TestParseMediaTypeExtParams(t *testing.T) {
+	tests := []struct {
+		in             string
+		expectedParams map[string]string
+	}{
+		{
+			`data:text/plain;title*=utf-8'en'%E2%82%AC%20rates,aGV5YQ==`,
+			map[string]string{"title": "€ rates"},
+		},
+		{
+			`data:text/plain;title*0=foo;title*1=bar,aGV5YQ==`,
+			map[string]string{"title": "foobar"},
+		},
+	}
+	for _, test := range tests {
+		du, err := DecodeString(test.in)
+		if err != nil {
+			t.Errorf("%s: %s", test.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(du.MediaType.Params, test.expectedParams) {
+			t.Errorf("%s: expected params %v, got %v", test.in, test.expectedParams, du.MediaType.Params)
+		}
+	}
+}
+
+func TestMediaTypeStringTokenVsQuoted(t *testing.T) {
+	mt := MediaType{"text", "plain", map[string]string{"charset": "utf-8"}}
+	if got, want := mt.String(), `text/plain; charset=utf-8`; got != want {
+		t.Errorf("expected unquoted token %q, got %q", want, got)
+	}
+
+	mt = MediaType{"text", "plain", map[string]string{"foo": "b \"<@>\" r"}}
+	if got, want := mt.String(), `text/plain; foo="b \"<@>\" r"`; got != want {
+		t.Errorf("expected quoted string %q, got %q", want, got)
+	}
+}
+
+// TestMediaTypeStringParsesBack checks that the "; " mime.FormatMediaType
+// puts between params in MediaType.String() is accepted back by the
+// scanner: it's easy to change one side of that contract and not
+// notice until a Params lookup silently comes back empty.
+func TestMediaTypeStringParsesBack(t *testing.T) {
+	mt := MediaType{"text", "plain", map[string]string{"charset": "utf-8"}}
+	du := DataURL{mt, EncodingBase64, []byte("heya")}
+
+	got, err := DecodeString(du.String())
+	if err != nil {
+		t.Fatalf("%q: %s", du.String(), err)
+	}
+	if got.Params["charset"] != "utf-8" {
+		t.Errorf("%q: expected Params[charset] = %q, got %q", du.String(), "utf-8", got.Params["charset"])
+	}
+}
+
+// TestStringDecodeRoundTrip guards against the scanner silently
+// mis-parsing its own String() output, e.g. the OWS that
+// mime.FormatMediaType inserts after ';' being swallowed into the next
+// param's attribute name.
+func TestStringDecodeRoundTrip(t *testing.T) {
+	for _, test := range genTestTable() {
+		if test.ExpectedErr != "" {
+			continue
+		}
+		du, err := DecodeString(test.InputRawDataURL)
+		if err != nil {
+			t.Errorf("%s: %s", test.InputRawDataURL, err)
+			continue
+		}
+		du2, err := DecodeString(du.String())
+		if err != nil {
+			t.Errorf("%s: String() -> %q: %s", test.InputRawDataURL, du.String(), err)
+			continue
+		}
+		if ok, err := equal(du, du2); err != nil {
+			t.Error(err)
+		} else if !ok {
+			t.Errorf("%s: String() -> %q round-tripped to %v, want %v", test.InputRawDataURL, du.String(), *du2, *du)
+		}
+	}
+}
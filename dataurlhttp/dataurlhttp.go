@@ -0,0 +1,76 @@
+// Package dataurlhttp integrates "data:" URLs with net/http, so code
+// that consumes mixed URL lists (HTML scrapers, feed processors, ...)
+// can treat them the same way it treats "http://" and "https://" URLs.
+package dataurlhttp
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/vincent-petithory/dataurl"
+)
+
+// Transport implements http.RoundTripper for requests whose URL has
+// the "data:" scheme, decoding them locally with dataurl.DecodeString
+// instead of making a network call.
+//
+//	client := &http.Client{Transport: dataurlhttp.New()}
+//	resp, err := client.Get("data:text/plain,hello")
+type Transport struct{}
+
+// New returns a Transport ready to use as an http.Client's Transport.
+func New() *Transport {
+	return &Transport{}
+}
+
+// RoundTrip implements http.RoundTripper. It returns an error if
+// req.URL doesn't have the "data:" scheme.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "data" {
+		return nil, fmt.Errorf("dataurlhttp: unsupported URL scheme %q", req.URL.Scheme)
+	}
+
+	du, err := dataurl.DecodeString(req.URL.String())
+	if err != nil {
+		return nil, err
+	}
+
+	header := make(http.Header)
+	header.Set("Content-Type", du.ContentType())
+	header.Set("Content-Length", fmt.Sprintf("%d", len(du.Data)))
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", http.StatusOK, http.StatusText(http.StatusOK)),
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.0",
+		ProtoMajor:    1,
+		ProtoMinor:    0,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(du.Data)),
+		ContentLength: int64(len(du.Data)),
+		Request:       req,
+	}, nil
+}
+
+// Handler serves a single data URL over HTTP, setting Content-Type
+// from its MediaType and an ETag computed as the SHA-256 of its data.
+// A request whose If-None-Match matches that ETag gets a 304 Not
+// Modified response with no body.
+func Handler(du *dataurl.DataURL) http.Handler {
+	sum := sha256.Sum256(du.Data)
+	etag := `"` + hex.EncodeToString(sum[:]) + `"`
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Content-Type", du.ContentType())
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write(du.Data)
+	})
+}
@@ -0,0 +1,90 @@
+package dataurlhttp
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/vincent-petithory/dataurl"
+)
+
+func TestTransportRoundTrip(t *testing.T) {
+	client := &http.Client{Transport: New()}
+	resp, err := client.Get("data:text/plain;base64,aGV5YQ==")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected status 200, got %d", resp.StatusCode)
+	}
+	if got, want := resp.Header.Get("Content-Type"), "text/plain"; got != want {
+		t.Errorf("expected Content-Type %q, got %q", want, got)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(body), "heya"; got != want {
+		t.Errorf("expected body %q, got %q", want, got)
+	}
+}
+
+func TestTransportRejectsOtherSchemes(t *testing.T) {
+	tr := New()
+	req, err := http.NewRequest("GET", "https://example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tr.RoundTrip(req); err == nil {
+		t.Error("expected error for non-data scheme, got nil")
+	}
+}
+
+func TestHandler(t *testing.T) {
+	du, err := dataurl.DecodeString("data:text/plain;base64,aGV5YQ==")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(Handler(du))
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if got, want := resp.Header.Get("Content-Type"), "text/plain"; got != want {
+		t.Errorf("expected Content-Type %q, got %q", want, got)
+	}
+	etag := resp.Header.Get("ETag")
+	if etag == "" {
+		t.Fatal("expected a non-empty ETag")
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(body), "heya"; got != want {
+		t.Errorf("expected body %q, got %q", want, got)
+	}
+
+	req, err := http.NewRequest("GET", ts.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("If-None-Match", etag)
+	resp2, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp2.Body.Close()
+	if resp2.StatusCode != http.StatusNotModified {
+		t.Errorf("expected status %d, got %d", http.StatusNotModified, resp2.StatusCode)
+	}
+}
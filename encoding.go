@@ -0,0 +1,92 @@
+package dataurl
+
+import (
+	"encoding/ascii85"
+	"encoding/base32"
+	"encoding/base64"
+	"io"
+	"sync"
+)
+
+const (
+	EncodingBase32    = "base32"
+	EncodingBase64URL = "base64url"
+	EncodingAscii85   = "ascii85"
+)
+
+// extraEncoding is how RegisterEncoding plugs a non-standard encoding
+// into the scanner and WriteTo: decode turns the payload text
+// back into raw bytes, encode wraps a writer to produce that payload
+// text from raw bytes.
+type extraEncoding struct {
+	decode encodedDataReader
+	encode func(io.Writer) io.WriteCloser
+}
+
+// extraEncodingsMu guards extraEncodings, the same way image.RegisterFormat
+// guards the image package's format registry: RegisterEncoding can race
+// with lookups made from Decode/Parse/WriteTo in concurrent callers.
+var extraEncodingsMu sync.RWMutex
+
+// extraEncodings holds every encoding beyond the RFC 2397 defaults
+// (EncodingBase64, EncodingASCII, handled directly by decodePayload and
+// WriteTo): the three built in below, plus whatever downstream code
+// has added with RegisterEncoding. Access only through
+// lookupExtraEncoding and extraEncodingNames.
+var extraEncodings = map[string]extraEncoding{
+	EncodingBase32: {
+		decode: func(s string) ([]byte, error) { return base32.StdEncoding.DecodeString(s) },
+		encode: func(w io.Writer) io.WriteCloser { return base32.NewEncoder(base32.StdEncoding, w) },
+	},
+	EncodingBase64URL: {
+		decode: func(s string) ([]byte, error) { return base64.URLEncoding.DecodeString(s) },
+		encode: func(w io.Writer) io.WriteCloser { return base64.NewEncoder(base64.URLEncoding, w) },
+	},
+	EncodingAscii85: {
+		decode: decodeAscii85,
+		encode: func(w io.Writer) io.WriteCloser { return ascii85.NewEncoder(w) },
+	},
+}
+
+// lookupExtraEncoding returns the extraEncoding registered under name,
+// if any.
+func lookupExtraEncoding(name string) (extraEncoding, bool) {
+	extraEncodingsMu.RLock()
+	defer extraEncodingsMu.RUnlock()
+	enc, ok := extraEncodings[name]
+	return enc, ok
+}
+
+// extraEncodingNames returns the names currently registered in
+// extraEncodings, in no particular order.
+func extraEncodingNames() []string {
+	extraEncodingsMu.RLock()
+	defer extraEncodingsMu.RUnlock()
+	names := make([]string, 0, len(extraEncodings))
+	for name := range extraEncodings {
+		names = append(names, name)
+	}
+	return names
+}
+
+func decodeAscii85(s string) ([]byte, error) {
+	src := []byte(s)
+	dst := make([]byte, len(src))
+	n, _, err := ascii85.Decode(dst, src, true)
+	if err != nil {
+		return nil, err
+	}
+	return dst[:n], nil
+}
+
+// RegisterEncoding makes DataURL recognize a non-standard encoding
+// name as a ";<name>" parameter, e.g. to add base58 without forking
+// this package. dec decodes the payload text into raw bytes, enc
+// wraps a writer to produce that payload text from raw bytes; both
+// follow the same contract as the built-in base32/base64url/ascii85
+// support.
+func RegisterEncoding(name string, dec func(string) ([]byte, error), enc func(io.Writer) io.WriteCloser) {
+	extraEncodingsMu.Lock()
+	defer extraEncodingsMu.Unlock()
+	extraEncodings[name] = extraEncoding{decode: dec, encode: enc}
+}
@@ -0,0 +1,113 @@
+package dataurl
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestExtraEncodingsRoundTrip(t *testing.T) {
+	tests := []struct {
+		enc  string
+		data []byte
+	}{
+		{EncodingBase32, []byte("heya")},
+		{EncodingBase64URL, []byte("heya")},
+		{EncodingAscii85, []byte("heya")},
+	}
+	for _, test := range tests {
+		du := &DataURL{
+			MediaType: MediaType{"text", "plain", map[string]string{}},
+			Encoding:  test.enc,
+			Data:      test.data,
+		}
+		s := du.String()
+		if !strings.Contains(s, ";"+test.enc+",") {
+			t.Errorf("%s: expected %q to contain %q", test.enc, s, ";"+test.enc+",")
+		}
+
+		got, err := DecodeString(s)
+		if err != nil {
+			t.Errorf("%s: %s", test.enc, err)
+			continue
+		}
+		if got.Encoding != test.enc {
+			t.Errorf("%s: expected encoding %s, got %s", test.enc, test.enc, got.Encoding)
+		}
+		if !bytes.Equal(got.Data, test.data) {
+			t.Errorf("%s: expected data %q, got %q", test.enc, test.data, got.Data)
+		}
+	}
+}
+
+func TestRegisterEncoding(t *testing.T) {
+	const encodingUpper = "upper"
+	RegisterEncoding(
+		encodingUpper,
+		func(s string) ([]byte, error) { return []byte(strings.ToLower(s)), nil },
+		func(w io.Writer) io.WriteCloser { return upperEncoder{w} },
+	)
+
+	du := &DataURL{
+		MediaType: MediaType{"text", "plain", map[string]string{}},
+		Encoding:  encodingUpper,
+		Data:      []byte("heya"),
+	}
+	s := du.String()
+	if !strings.Contains(s, "HEYA") {
+		t.Errorf("expected %q to contain upper-cased data, got %q", s, s)
+	}
+
+	got, err := DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Encoding != encodingUpper {
+		t.Errorf("expected encoding %s, got %s", encodingUpper, got.Encoding)
+	}
+	if !bytes.Equal(got.Data, du.Data) {
+		t.Errorf("expected data %q, got %q", du.Data, got.Data)
+	}
+}
+
+// TestRegisterEncodingConcurrent guards the extraEncodings registry
+// against concurrent RegisterEncoding calls racing Decode/WriteTo
+// lookups, e.g. via "go test -race".
+func TestRegisterEncodingConcurrent(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			name := fmt.Sprintf("concurrent%d", i)
+			RegisterEncoding(
+				name,
+				func(s string) ([]byte, error) { return []byte(s), nil },
+				func(w io.Writer) io.WriteCloser { return upperEncoder{w} },
+			)
+			du := &DataURL{
+				MediaType: MediaType{"text", "plain", map[string]string{}},
+				Encoding:  name,
+				Data:      []byte("heya"),
+			}
+			if _, err := DecodeString(du.String()); err != nil {
+				t.Errorf("%s: %s", name, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+type upperEncoder struct {
+	w io.Writer
+}
+
+func (u upperEncoder) Write(p []byte) (int, error) {
+	_, err := u.w.Write([]byte(strings.ToUpper(string(p))))
+	return len(p), err
+}
+
+func (u upperEncoder) Close() error { return nil }
@@ -0,0 +1,152 @@
+package dataurl
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+)
+
+// MarshalJSON implements the json.Marshaler interface. The DataURL is
+// encoded as its compact "data:..." string form.
+func (du *DataURL) MarshalJSON() ([]byte, error) {
+	return json.Marshal(du.String())
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (du *DataURL) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	parsed, err := DecodeString(s)
+	if err != nil {
+		return err
+	}
+	*du = *parsed
+	return nil
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (du *DataURL) MarshalText() ([]byte, error) {
+	return []byte(du.String()), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface.
+func (du *DataURL) UnmarshalText(text []byte) error {
+	parsed, err := DecodeString(string(text))
+	if err != nil {
+		return err
+	}
+	*du = *parsed
+	return nil
+}
+
+// Scan implements the database/sql Scanner interface, so a DataURL can
+// be read directly out of a TEXT or BLOB column holding its string form.
+func (du *DataURL) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case string:
+		return du.UnmarshalText([]byte(v))
+	case []byte:
+		return du.UnmarshalText(v)
+	case nil:
+		return errors.New("dataurl: cannot scan NULL into *DataURL")
+	default:
+		return fmt.Errorf("dataurl: cannot scan %T into *DataURL", src)
+	}
+}
+
+// Value implements the database/sql/driver Valuer interface, so a
+// DataURL can be written directly to a TEXT column.
+func (du *DataURL) Value() (driver.Value, error) {
+	return du.String(), nil
+}
+
+// binaryFormatVersion is the first byte of the encoding.BinaryMarshaler
+// framing below, bumped if that framing ever changes incompatibly.
+const binaryFormatVersion = 1
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface.
+//
+// It uses a compact framing instead of the usual "data:" string form:
+// a 1-byte version, the media type as a varint-prefixed string, and
+// the data as a varint-prefixed byte slice, stored raw rather than
+// base64-encoded. This is meant for gob and on-disk caching, where
+// the base64 step is pure overhead.
+func (du *DataURL) MarshalBinary() ([]byte, error) {
+	mt := []byte(du.MediaType.String())
+
+	var buf bytes.Buffer
+	buf.WriteByte(binaryFormatVersion)
+
+	var lenBuf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(lenBuf[:], uint64(len(mt)))
+	buf.Write(lenBuf[:n])
+	buf.Write(mt)
+
+	n = binary.PutUvarint(lenBuf[:], uint64(len(du.Data)))
+	buf.Write(lenBuf[:n])
+	buf.Write(du.Data)
+
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+// See MarshalBinary for the framing. The resulting DataURL always has
+// EncodingBase64 as its Encoding, since that's what Data would be
+// re-encoded with if turned back into the "data:" string form.
+func (du *DataURL) UnmarshalBinary(data []byte) error {
+	r := bytes.NewReader(data)
+
+	version, err := r.ReadByte()
+	if err != nil {
+		return err
+	}
+	if version != binaryFormatVersion {
+		return fmt.Errorf("dataurl: unsupported binary format version %d", version)
+	}
+
+	mtLen, err := binary.ReadUvarint(r)
+	if err != nil {
+		return err
+	}
+	if mtLen > uint64(r.Len()) {
+		return fmt.Errorf("dataurl: media type length %d exceeds remaining input", mtLen)
+	}
+	mt := make([]byte, mtLen)
+	if _, err := io.ReadFull(r, mt); err != nil {
+		return err
+	}
+
+	dataLen, err := binary.ReadUvarint(r)
+	if err != nil {
+		return err
+	}
+	if dataLen > uint64(r.Len()) {
+		return fmt.Errorf("dataurl: data length %d exceeds remaining input", dataLen)
+	}
+	payload := make([]byte, dataLen)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return err
+	}
+
+	ct, params, err := mime.ParseMediaType(string(mt))
+	if err != nil {
+		return err
+	}
+	parts := strings.SplitN(ct, "/", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("dataurl: invalid media type %q", ct)
+	}
+
+	du.MediaType = MediaType{parts[0], parts[1], params}
+	du.Encoding = EncodingBase64
+	du.Data = payload
+	return nil
+}
@@ -0,0 +1,142 @@
+package dataurl
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+)
+
+func TestJSONMarshaling(t *testing.T) {
+	du, err := DecodeString(`data:text/plain;charset=utf-8;base64,aGV5YQ==`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := json.Marshal(du)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got DataURL
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := equal(du, &got); err != nil {
+		t.Fatal(err)
+	} else if !ok {
+		t.Errorf("expected %v, got %v", du, got)
+	}
+}
+
+func TestTextMarshaling(t *testing.T) {
+	du, err := DecodeString(`data:text/plain;charset=utf-8;base64,aGV5YQ==`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := du.MarshalText()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got DataURL
+	if err := got.UnmarshalText(b); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := equal(du, &got); err != nil {
+		t.Fatal(err)
+	} else if !ok {
+		t.Errorf("expected %v, got %v", du, got)
+	}
+}
+
+func TestScanValue(t *testing.T) {
+	du, err := DecodeString(`data:text/plain;charset=utf-8;base64,aGV5YQ==`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := du.Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got DataURL
+	if err := got.Scan(v); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := equal(du, &got); err != nil {
+		t.Fatal(err)
+	} else if !ok {
+		t.Errorf("expected %v, got %v", du, got)
+	}
+
+	if err := got.Scan(nil); err == nil {
+		t.Error("expected error scanning nil, got nil")
+	}
+	if err := got.Scan(42); err == nil {
+		t.Error("expected error scanning int, got nil")
+	}
+}
+
+func TestBinaryMarshaling(t *testing.T) {
+	du, err := DecodeString(`data:image/png;base64,aGV5YQ==`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := du.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(b, []byte("aGV5YQ==")) {
+		t.Error("expected binary framing to store raw data, not base64 text")
+	}
+
+	var got DataURL
+	if err := got.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+	if got.Type != du.Type || got.Subtype != du.Subtype {
+		t.Errorf("expected media type %v, got %v", du.MediaType, got.MediaType)
+	}
+	if !bytes.Equal(got.Data, du.Data) {
+		t.Errorf("expected data %q, got %q", du.Data, got.Data)
+	}
+	if got.Encoding != EncodingBase64 {
+		t.Errorf("expected encoding %s, got %s", EncodingBase64, got.Encoding)
+	}
+}
+
+func TestUnmarshalBinaryWrongVersion(t *testing.T) {
+	var du DataURL
+	if err := du.UnmarshalBinary([]byte{0xff}); err == nil {
+		t.Error("expected error for unsupported version, got nil")
+	}
+}
+
+func TestUnmarshalBinaryForgedLength(t *testing.T) {
+	var lenBuf [binary.MaxVarintLen64]byte
+
+	n := binary.PutUvarint(lenBuf[:], 1<<34)
+	forged := append([]byte{binaryFormatVersion}, lenBuf[:n]...)
+	var du DataURL
+	if err := du.UnmarshalBinary(forged); err == nil {
+		t.Error("expected error for media type length exceeding input, got nil")
+	}
+
+	n = binary.PutUvarint(lenBuf[:], 1<<62)
+	forged = append([]byte{binaryFormatVersion}, lenBuf[:n]...)
+	if err := du.UnmarshalBinary(forged); err == nil {
+		t.Error("expected error for implausible media type length, got nil")
+	}
+
+	n = binary.PutUvarint(lenBuf[:], 0)
+	forged = append([]byte{binaryFormatVersion}, lenBuf[:n]...)
+	n2 := binary.PutUvarint(lenBuf[:], 1<<34)
+	forged = append(forged, lenBuf[:n2]...)
+	if err := du.UnmarshalBinary(forged); err == nil {
+		t.Error("expected error for data length exceeding input, got nil")
+	}
+}
@@ -0,0 +1,273 @@
+package dataurl
+
+import (
+	"errors"
+	"mime"
+	"strconv"
+	"strings"
+)
+
+const dataPrefix = "data:"
+
+// scanner is a single-pass, byte-scanning reader of a data URL's
+// header ("data:<mediatype>[;params][;<encoding>],"). It replaces an
+// earlier implementation that ran a goroutine feeding parsed tokens
+// over a channel; scanning in place avoids that overhead, and, for
+// the common case of a data URL with no parameters, avoids allocating
+// anything beyond the MediaType's Type/Subtype strings and the
+// decoded Data.
+type scanner struct {
+	b   []byte
+	pos int
+}
+
+func (s *scanner) eof() bool {
+	return s.pos >= len(s.b)
+}
+
+func (s *scanner) peek() byte {
+	if s.eof() {
+		return 0
+	}
+	return s.b[s.pos]
+}
+
+// hasPrefixAt reports whether kw occurs at the scanner's current
+// position, without allocating.
+func (s *scanner) hasPrefixAt(kw string) bool {
+	if len(s.b)-s.pos < len(kw) {
+		return false
+	}
+	for i := 0; i < len(kw); i++ {
+		if s.b[s.pos+i] != kw[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// matchKeywordComma reports whether kw occurs at the scanner's current
+// position immediately followed by ',' or the end of input, i.e. as
+// the encoding keyword of a ";<kw>," parameter. On a match it consumes
+// kw, leaving the comma for the caller.
+func (s *scanner) matchKeywordComma(kw string) bool {
+	if !s.hasPrefixAt(kw) {
+		return false
+	}
+	after := s.pos + len(kw)
+	if after != len(s.b) && s.b[after] != ',' {
+		return false
+	}
+	s.pos = after
+	return true
+}
+
+// isTokenByte reports whether c can be part of a media type, subtype,
+// parameter attribute or unquoted parameter value, i.e. everything
+// except the delimiters the scanner itself cares about.
+func isTokenByte(c byte) bool {
+	switch c {
+	case '/', ';', ',', '=', '"':
+		return false
+	}
+	return true
+}
+
+// scanHeader parses "data:<mediatype>[;params][;<encoding>]," into du,
+// consuming up to and including the comma. What remains in s.b[s.pos:]
+// is the still-encoded payload.
+func (s *scanner) scanHeader(du *DataURL) error {
+	if !s.hasPrefixAt(dataPrefix) {
+		return errors.New("dataurl: missing data: prefix")
+	}
+	s.pos += len(dataPrefix)
+
+	if err := s.scanMediaType(du); err != nil {
+		return err
+	}
+	return s.scanParams(du)
+}
+
+func (s *scanner) scanMediaType(du *DataURL) error {
+	start := s.pos
+	for {
+		if s.eof() {
+			return errors.New("dataurl: unexpected end of input in media type")
+		}
+		switch c := s.b[s.pos]; {
+		case c == '/':
+			if s.pos == start {
+				return errors.New("invalid character for media type")
+			}
+			du.MediaType.Type = string(s.b[start:s.pos])
+			delete(du.MediaType.Params, "charset")
+			s.pos++
+			return s.scanMediaSubType(du)
+		case c == ';' || c == ',':
+			if s.pos != start {
+				return errors.New("invalid character for media type")
+			}
+			return nil
+		case isTokenByte(c):
+			s.pos++
+		default:
+			return errors.New("invalid character for media type")
+		}
+	}
+}
+
+func (s *scanner) scanMediaSubType(du *DataURL) error {
+	start := s.pos
+	for {
+		if s.eof() {
+			return errors.New("dataurl: unexpected end of input in media subtype")
+		}
+		switch c := s.b[s.pos]; {
+		case c == ';' || c == ',':
+			if s.pos == start {
+				return errors.New("invalid character for media subtype")
+			}
+			du.MediaType.Subtype = string(s.b[start:s.pos])
+			return nil
+		case isTokenByte(c):
+			s.pos++
+		default:
+			return errors.New("invalid character for media subtype")
+		}
+	}
+}
+
+// scanParams consumes the ";attr=val" and ";<encoding>" groups that
+// follow the media type, up to and including the terminating comma.
+//
+// Plain attr=val params are decoded directly, matching the legacy
+// Escape-based and quoted-string handling. If any attribute uses RFC
+// 2231 syntax (name*=, name*0=, ...), the whole parameter section is
+// re-parsed with mime.ParseMediaType once scanning reaches the comma,
+// and its result replaces du.MediaType.Params wholesale.
+//
+// mime.FormatMediaType, used by MediaType.String, inserts a single OWS
+// (optional whitespace) after each ';' before the attribute, so that is
+// skipped here too: without it, String's output wouldn't round-trip
+// back through Decode/Parse.
+func (s *scanner) scanParams(du *DataURL) error {
+	var rawParams strings.Builder
+	hasExtParam := false
+
+	for {
+		if s.eof() {
+			return errors.New("dataurl: unexpected end of input")
+		}
+		switch s.b[s.pos] {
+		case ';':
+			s.pos++
+			for !s.eof() && s.b[s.pos] == ' ' {
+				s.pos++
+			}
+			if s.matchKeywordComma(EncodingBase64) {
+				du.Encoding = EncodingBase64
+				continue
+			}
+			if enc, ok := s.matchExtraEncoding(); ok {
+				du.Encoding = enc
+				continue
+			}
+
+			rawParams.WriteByte(';')
+			attrStart := s.pos
+			for !s.eof() && s.b[s.pos] != '=' && isTokenByte(s.b[s.pos]) {
+				s.pos++
+			}
+			if s.pos == attrStart || s.eof() || s.b[s.pos] != '=' {
+				return errors.New("invalid character for param attribute")
+			}
+			attr := string(s.b[attrStart:s.pos])
+			rawParams.Write(s.b[attrStart:s.pos])
+			rawParams.WriteByte('=')
+			s.pos++ // consume '='
+			if strings.ContainsRune(attr, '*') {
+				hasExtParam = true
+			}
+
+			val, quoted, err := s.scanParamVal(&rawParams)
+			if err != nil {
+				return err
+			}
+			if quoted {
+				unquoted, err := strconv.Unquote(`"` + val + `"`)
+				if err != nil {
+					return err
+				}
+				du.MediaType.Params[attr] = unquoted
+			} else {
+				unescaped, err := UnescapeToString(val)
+				if err != nil {
+					return err
+				}
+				du.MediaType.Params[attr] = unescaped
+			}
+		case ',':
+			s.pos++
+			if hasExtParam {
+				if _, params, err := mime.ParseMediaType(du.MediaType.ContentType() + rawParams.String()); err == nil {
+					du.MediaType.Params = params
+				}
+			}
+			return nil
+		default:
+			return errors.New("dataurl: invalid character, expected ';' or ','")
+		}
+	}
+}
+
+// matchExtraEncoding checks the scanner's current position against
+// every encoding known to extraEncodings (the built-in base32,
+// base64url and ascii85, plus anything added with RegisterEncoding).
+func (s *scanner) matchExtraEncoding() (string, bool) {
+	for _, name := range extraEncodingNames() {
+		if s.matchKeywordComma(name) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// scanParamVal scans a parameter value, quoted or not, also echoing it
+// verbatim (backslash escapes and surrounding quotes included) to raw,
+// so the caller can fall back to mime.ParseMediaType on the exact
+// original text if needed.
+func (s *scanner) scanParamVal(raw *strings.Builder) (val string, quoted bool, err error) {
+	if s.peek() != '"' {
+		start := s.pos
+		for !s.eof() && s.b[s.pos] != ';' && s.b[s.pos] != ',' {
+			s.pos++
+		}
+		val = string(s.b[start:s.pos])
+		raw.WriteString(val)
+		return val, false, nil
+	}
+
+	raw.WriteByte('"')
+	s.pos++ // consume opening quote
+	start := s.pos
+	for {
+		if s.eof() {
+			return "", false, errors.New("dataurl: unexpected end of input in quoted param value")
+		}
+		switch s.b[s.pos] {
+		case '\\':
+			if s.pos+1 >= len(s.b) {
+				return "", false, errors.New("dataurl: unexpected end of input in quoted param value")
+			}
+			s.pos += 2
+		case '"':
+			val = string(s.b[start:s.pos])
+			raw.WriteString(val)
+			raw.WriteByte('"')
+			s.pos++ // consume closing quote
+			return val, true, nil
+		default:
+			s.pos++
+		}
+	}
+}
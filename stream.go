@@ -0,0 +1,206 @@
+package dataurl
+
+import (
+	"bufio"
+	"encoding/ascii85"
+	"encoding/base32"
+	"encoding/base64"
+	"errors"
+	"io"
+	"strconv"
+)
+
+// NewEncoder returns a WriteCloser that writes a data URL to w,
+// encoding bytes written to it with enc: EncodingBase64, EncodingASCII,
+// or any encoding known to extraEncodings (EncodingBase32,
+// EncodingBase64URL, EncodingAscii85, or one added with
+// RegisterEncoding).
+//
+// The "data:<mediatype>[;<enc>]," header is written lazily, on the
+// first call to Write or Close, so creating an encoder produces no
+// output until one of those is called. The caller must Close the encoder
+// once done writing, to flush any buffered encoded data.
+//
+// Unlike DataURL.WriteTo, NewEncoder never buffers the payload: data
+// written to it is streamed to w as it comes in, which makes it
+// suitable for large payloads such as images or PDFs.
+func NewEncoder(w io.Writer, mt MediaType, enc string) (io.WriteCloser, error) {
+	var wrap func(io.Writer) io.WriteCloser
+	switch enc {
+	case EncodingASCII:
+	case EncodingBase64:
+		wrap = func(w io.Writer) io.WriteCloser { return base64.NewEncoder(base64.StdEncoding, w) }
+	default:
+		def, ok := lookupExtraEncoding(enc)
+		if !ok {
+			return nil, errors.New("dataurl: invalid encoding " + enc)
+		}
+		wrap = def.encode
+	}
+
+	header := "data:" + mt.String()
+	if enc != EncodingASCII {
+		header += ";" + enc
+	}
+	header += ","
+	return &encoder{w: w, header: header, ascii: enc == EncodingASCII, wrap: wrap}, nil
+}
+
+type encoder struct {
+	w      io.Writer
+	header string
+	ascii  bool
+	wrap   func(io.Writer) io.WriteCloser
+	wc     io.WriteCloser
+	wrote  bool
+}
+
+func (e *encoder) writeHeader() error {
+	if e.wrote {
+		return nil
+	}
+	e.wrote = true
+	if _, err := io.WriteString(e.w, e.header); err != nil {
+		return err
+	}
+	if !e.ascii {
+		e.wc = e.wrap(e.w)
+	}
+	return nil
+}
+
+func (e *encoder) Write(p []byte) (int, error) {
+	if err := e.writeHeader(); err != nil {
+		return 0, err
+	}
+	if !e.ascii {
+		return e.wc.Write(p)
+	}
+	if _, err := io.WriteString(e.w, Escape(p)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (e *encoder) Close() error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+	if e.wc != nil {
+		return e.wc.Close()
+	}
+	return nil
+}
+
+// NewDecoder reads the "data:<mediatype>[;base64]," header of a data URL
+// from r and returns the DataURL it describes, along with an io.Reader
+// for the remaining, still-encoded payload.
+//
+// Unlike Decode, the payload is never read into memory by NewDecoder:
+// du.Data is left empty, and the returned io.Reader decodes the
+// payload as it is read, wrapping a streaming decoder for du.Encoding
+// (base64, base32, base64url or ascii85) or, for EncodingASCII, an
+// unescaping reader. This makes it suitable for streaming large
+// payloads straight to their destination (a file, a hash, another
+// io.Writer) without allocating the whole blob.
+//
+// Encodings added with RegisterEncoding are not supported here, since
+// their decode func works on a whole string rather than a stream; use
+// Decode for those.
+func NewDecoder(r io.Reader) (*DataURL, io.Reader, error) {
+	br := bufio.NewReader(r)
+	header, err := readHeader(br)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	du := &DataURL{
+		MediaType: defaultMediaType(),
+		Encoding:  EncodingASCII,
+	}
+	s := &scanner{b: []byte(header)}
+	if err := s.scanHeader(du); err != nil {
+		return nil, nil, err
+	}
+	du.Data = nil
+
+	var data io.Reader
+	switch du.Encoding {
+	case EncodingBase64:
+		data = base64.NewDecoder(base64.StdEncoding, br)
+	case EncodingBase64URL:
+		data = base64.NewDecoder(base64.URLEncoding, br)
+	case EncodingBase32:
+		data = base32.NewDecoder(base32.StdEncoding, br)
+	case EncodingAscii85:
+		data = ascii85.NewDecoder(br)
+	case EncodingASCII:
+		data = &unescapingReader{br: br}
+	default:
+		return nil, nil, errors.New("dataurl: NewDecoder doesn't support streaming decode for " + du.Encoding)
+	}
+	return du, data, nil
+}
+
+// readHeader reads from br up to and including the comma that
+// separates the data URL header from its payload, honoring
+// backslash-escapes within double-quoted parameter values so a comma
+// there doesn't end the header early.
+func readHeader(br *bufio.Reader) (string, error) {
+	var header []byte
+	var inQuotes, escaped bool
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			if err == io.EOF {
+				return "", errors.New("dataurl: missing comma in data URL")
+			}
+			return "", err
+		}
+		header = append(header, b)
+		switch {
+		case escaped:
+			escaped = false
+		case b == '\\' && inQuotes:
+			escaped = true
+		case b == '"':
+			inQuotes = !inQuotes
+		case b == ',' && !inQuotes:
+			return string(header), nil
+		}
+	}
+}
+
+// unescapingReader decodes a %XX-escaped stream as it is read,
+// mirroring Unescape without buffering the whole input.
+type unescapingReader struct {
+	br *bufio.Reader
+}
+
+func (u *unescapingReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		b, err := u.br.ReadByte()
+		if err != nil {
+			if err == io.EOF && n > 0 {
+				return n, nil
+			}
+			return n, err
+		}
+		if b == '%' {
+			hex := make([]byte, 2)
+			if _, err := io.ReadFull(u.br, hex); err != nil {
+				return n, err
+			}
+			v, err := strconv.ParseUint(string(hex), 16, 8)
+			if err != nil {
+				return n, err
+			}
+			p[n] = byte(v)
+		} else {
+			p[n] = b
+		}
+		n++
+	}
+	return n, nil
+}
@@ -0,0 +1,111 @@
+package dataurl
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+func TestEncoderDecoderRoundTrip(t *testing.T) {
+	tests := []struct {
+		mt   MediaType
+		enc  string
+		data []byte
+	}{
+		{
+			MediaType{"text", "plain", map[string]string{}},
+			EncodingBase64,
+			[]byte("heya"),
+		},
+		{
+			MediaType{"text", "plain", map[string]string{}},
+			EncodingASCII,
+			[]byte("A brief note"),
+		},
+		{
+			MediaType{"image", "png", map[string]string{}},
+			EncodingBase64,
+			bytes.Repeat([]byte{0x89, 'P', 'N', 'G', 0x00, 0x01}, 1024),
+		},
+	}
+	for _, test := range tests {
+		var buf bytes.Buffer
+		enc, err := NewEncoder(&buf, test.mt, test.enc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := enc.Write(test.data); err != nil {
+			t.Fatal(err)
+		}
+		if err := enc.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		du, r, err := NewDecoder(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflectMediaTypeEqual(du.MediaType, test.mt) {
+			t.Errorf("expected MediaType %v, got %v", test.mt, du.MediaType)
+		}
+		if du.Encoding != test.enc {
+			t.Errorf("expected encoding %s, got %s", test.enc, du.Encoding)
+		}
+		got, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, test.data) {
+			t.Errorf("expected data %q, got %q", test.data, got)
+		}
+	}
+}
+
+func TestNewEncoderInvalidEncoding(t *testing.T) {
+	var buf bytes.Buffer
+	if _, err := NewEncoder(&buf, defaultMediaType(), "foo"); err == nil {
+		t.Error("expected error for invalid encoding, got nil")
+	}
+}
+
+func TestNewEncoderNoWriteNoOutput(t *testing.T) {
+	var buf bytes.Buffer
+	enc, err := NewEncoder(&buf, defaultMediaType(), EncodingASCII)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("expected no output before first Write, got %q", buf.String())
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() == 0 {
+		t.Error("expected header to be flushed on Close")
+	}
+}
+
+func TestNewDecoderMissingComma(t *testing.T) {
+	_, _, err := NewDecoder(bytes.NewReader([]byte("data:text/plain;base64")))
+	if err == nil {
+		t.Error("expected error for missing comma, got nil")
+	}
+}
+
+func reflectMediaTypeEqual(a, b MediaType) bool {
+	if a.Type != b.Type || a.Subtype != b.Subtype {
+		return false
+	}
+	if len(a.Params) != len(b.Params) {
+		return false
+	}
+	for k, v := range a.Params {
+		if b.Params[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+var _ io.Reader = (*unescapingReader)(nil)